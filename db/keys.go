@@ -0,0 +1,65 @@
+package db
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+// encodeKey builds a "<prefix><varint worker name length><worker name
+// bytes><varint timestamp>" key for the raw stat and rollup tiers. The
+// worker name is length-prefixed rather than packed into a fixed-width
+// field, so it's never silently truncated: Ethereum stratum worker ids
+// are conventionally "<40-char address>.<worker>", already past any
+// small fixed bound on their own, and truncating two such names down to
+// a shared prefix would silently merge their stats. This must match the
+// encoding schema's length-prefixed-keys migration writes (see
+// db/schema).
+func encodeKey(prefix string, workerName string, timestamp int64) []byte {
+	nameLenBuf := make([]byte, binary.MaxVarintLen64)
+	nameLenN := binary.PutUvarint(nameLenBuf, uint64(len(workerName)))
+
+	tsBuf := make([]byte, binary.MaxVarintLen64)
+	tsN := binary.PutVarint(tsBuf, timestamp)
+
+	key := make([]byte, 0, len(prefix)+nameLenN+len(workerName)+tsN)
+	key = append(key, prefix...)
+	key = append(key, nameLenBuf[:nameLenN]...)
+	key = append(key, workerName...)
+	key = append(key, tsBuf[:tsN]...)
+	return key
+}
+
+// decodeKey parses a key built by encodeKey, returning the worker name
+// and the timestamp.
+func decodeKey(prefix string, key []byte) (workerName string, timestamp int64, err error) {
+	rest := key[len(prefix):]
+
+	nameLen, n := binary.Uvarint(rest)
+	if n <= 0 {
+		return "", 0, errors.New("invalid varint worker name length")
+	}
+	rest = rest[n:]
+
+	if uint64(len(rest)) < nameLen {
+		return "", 0, errors.New("key too short for its worker name")
+	}
+	workerName = string(rest[:nameLen])
+	rest = rest[nameLen:]
+
+	timestamp, n = binary.Varint(rest)
+	if n <= 0 {
+		return "", 0, errors.New("invalid varint timestamp")
+	}
+
+	return workerName, timestamp, nil
+}
+
+// encodeBestShareKey appends an 8-byte random suffix after the
+// timestamp, to disambiguate multiple best shares landing in the same
+// second for the same worker.
+func encodeBestShareKey(workerName string, timestamp int64, rand uint64) []byte {
+	key := encodeKey(bestSharePrefix, workerName, timestamp)
+	randBytes := make([]byte, 8)
+	binary.BigEndian.PutUint64(randBytes, rand)
+	return append(key, randBytes...)
+}
@@ -0,0 +1,54 @@
+package db
+
+import "testing"
+
+func TestEncodeDecodeKeyRoundTrip(t *testing.T) {
+	cases := []struct {
+		worker    string
+		timestamp int64
+	}{
+		{"short", 100},
+		{"", 0},
+		{"0x000000000000000000000000000000000000aa.rig-number-one-very-long-name", 1_700_000_000},
+	}
+
+	for _, c := range cases {
+		key := encodeKey(statPrefix, c.worker, c.timestamp)
+		worker, timestamp, err := decodeKey(statPrefix, key)
+		if err != nil {
+			t.Fatalf("decodeKey(%q): %v", c.worker, err)
+		}
+		if worker != c.worker {
+			t.Errorf("worker = %q, want %q", worker, c.worker)
+		}
+		if timestamp != c.timestamp {
+			t.Errorf("timestamp = %d, want %d", timestamp, c.timestamp)
+		}
+	}
+}
+
+// TestEncodeKeyDoesNotCollideOnLongWorkerNames guards against the bug a
+// fixed-width, truncating worker id reintroduced: two distinct workers
+// whose names share a long common prefix (as real Ethereum stratum
+// worker ids do -- "<40-char address>.<worker>") must never encode to
+// the same key.
+func TestEncodeKeyDoesNotCollideOnLongWorkerNames(t *testing.T) {
+	worker1 := "0x000000000000000000000000000000000000aa.rig-one"
+	worker2 := "0x000000000000000000000000000000000000aa.rig-two"
+
+	key1 := encodeKey(statPrefix, worker1, 100)
+	key2 := encodeKey(statPrefix, worker2, 100)
+
+	if string(key1) == string(key2) {
+		t.Fatalf("distinct workers %q and %q encoded to the same key", worker1, worker2)
+	}
+
+	w1, _, err := decodeKey(statPrefix, key1)
+	if err != nil || w1 != worker1 {
+		t.Fatalf("decodeKey(key1) = %q, %v, want %q, nil", w1, err, worker1)
+	}
+	w2, _, err := decodeKey(statPrefix, key2)
+	if err != nil || w2 != worker2 {
+		t.Fatalf("decodeKey(key2) = %q, %v, want %q, nil", w2, err, worker2)
+	}
+}
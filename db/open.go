@@ -0,0 +1,27 @@
+package db
+
+import (
+	"fmt"
+
+	"github.com/flexpool/solo/db/schema"
+	"github.com/syndtr/goleveldb/leveldb"
+)
+
+// Open opens (or creates) the LevelDB store at path, migrating it up to
+// the current schema version first. It refuses to open a database whose
+// stored schema version is newer than this binary knows about, since
+// that almost always means an older binary has been pointed at a
+// database a newer one already wrote to.
+func Open(path string) (*Database, error) {
+	ldb, err := leveldb.OpenFile(path, nil)
+	if err != nil {
+		return nil, fmt.Errorf("opening leveldb at %s: %w", path, err)
+	}
+
+	if err := schema.Migrate(ldb); err != nil {
+		ldb.Close()
+		return nil, fmt.Errorf("migrating schema: %w", err)
+	}
+
+	return &Database{DB: ldb}, nil
+}
@@ -0,0 +1,230 @@
+package db
+
+import (
+	"sync/atomic"
+
+	"github.com/flexpool/solo/log"
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/util"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// Rollup tiers. Raw share rows are kept for a short window, then folded
+// into progressively coarser, pre-aggregated buckets, the same way
+// block-explorer/pool backends downsample time-series data, so neither
+// PruneStats nor a stats dashboard ever has to scan one row per share.
+const (
+	rollup1mPrefix = "rollup1m__"
+	rollup1hPrefix = "rollup1h__"
+	rollup1dPrefix = "rollup1d__"
+)
+
+// Tier bucket widths, in seconds.
+const (
+	bucket1m = int64(60)
+	bucket1h = int64(60 * 60)
+	bucket1d = int64(24 * 60 * 60)
+)
+
+// Tier retentions, in seconds: how long a tier's buckets live before
+// Rollup folds them into the next coarser tier (or, for the final tier,
+// PruneStats deletes them outright).
+const (
+	rollup1mRetention = int64(24 * 60 * 60)       // 1 day of 1-minute buckets
+	rollup1hRetention = int64(30 * 24 * 60 * 60)  // 30 days of 1-hour buckets
+	rollup1dRetention = int64(365 * 24 * 60 * 60) // 1 year of 1-day buckets
+)
+
+// hashrateEWMAAlpha weights how much a newly-rolled-up bucket moves the
+// running hashrate average relative to what was already stored for it.
+const hashrateEWMAAlpha = 0.2
+
+// RollupStat is a pre-aggregated bucket of share activity for one
+// worker, as stored under rollup1mPrefix/rollup1hPrefix/rollup1dPrefix.
+type RollupStat struct {
+	WorkerName        string
+	ValidShareCount   uint64
+	StaleShareCount   uint64
+	InvalidShareCount uint64
+	HashrateEWMA      float64
+}
+
+// Rollup folds completed buckets from one tier into the next: raw rows
+// older than the raw retention window into 1-minute buckets, 1-minute
+// buckets older than their retention into 1-hour buckets, and 1-hour
+// buckets older than their retention into 1-day buckets. It's meant to
+// be called periodically (e.g. once a minute) alongside PruneStats.
+func (db *Database) Rollup(now int64) error {
+	if err := db.rollupTier(statPrefix, rollup1mPrefix, bucket1m, now-rawStatRetention); err != nil {
+		return err
+	}
+	if err := db.rollupTier(rollup1mPrefix, rollup1hPrefix, bucket1h, now-rollup1mRetention); err != nil {
+		return err
+	}
+	return db.rollupTier(rollup1hPrefix, rollup1dPrefix, bucket1d, now-rollup1hRetention)
+}
+
+// rawStatRetention is how long raw per-share rows are kept untouched
+// before Rollup starts folding them into 1-minute buckets.
+const rawStatRetention = int64(60 * 60)
+
+type bucketAccumulator struct {
+	worker                string
+	valid, stale, invalid uint64
+	hashrateEWMA          float64
+}
+
+// rollupTier aggregates every row in srcPrefix older than olderThan into
+// bucketSize-wide buckets under dstPrefix, writes the result in a single
+// batch, and deletes the rows it just folded in the same batch.
+func (db *Database) rollupTier(srcPrefix, dstPrefix string, bucketSize, olderThan int64) error {
+	iter := db.DB.NewIterator(util.BytesPrefix([]byte(srcPrefix)), nil)
+	defer iter.Release()
+
+	buckets := make(map[string]*bucketAccumulator)
+	var foldedKeys [][]byte
+
+	for iter.Next() {
+		key := iter.Key()
+		worker, timestamp, err := decodeKey(srcPrefix, key)
+		if err != nil {
+			atomic.AddUint64(&corruptionCount, 1)
+			log.WithFields(log.Fields{
+				"prefix": "db",
+				"key":    string(key),
+				"error":  err,
+			}).Error("Skipping corrupt row while rolling up")
+			continue
+		}
+
+		if timestamp >= olderThan {
+			continue
+		}
+
+		valid, stale, invalid, hashrate, err := decodeTierValue(srcPrefix, iter.Value())
+		if err != nil {
+			atomic.AddUint64(&corruptionCount, 1)
+			log.WithFields(log.Fields{
+				"prefix": "db",
+				"key":    string(key),
+				"error":  err,
+			}).Error("Skipping corrupt row while rolling up")
+			continue
+		}
+
+		bucketStart := timestamp - (timestamp % bucketSize)
+		dstKey := string(encodeKey(dstPrefix, worker, bucketStart))
+
+		acc, ok := buckets[dstKey]
+		if !ok {
+			acc = &bucketAccumulator{worker: worker}
+			buckets[dstKey] = acc
+		}
+		acc.valid += valid
+		acc.stale += stale
+		acc.invalid += invalid
+		acc.hashrateEWMA = hashrateEWMAAlpha*hashrate + (1-hashrateEWMAAlpha)*acc.hashrateEWMA
+
+		foldedKeys = append(foldedKeys, append([]byte(nil), key...))
+	}
+	if err := iter.Error(); err != nil {
+		return err
+	}
+
+	if len(buckets) == 0 {
+		return nil
+	}
+
+	batch := new(leveldb.Batch)
+
+	for dstKey, acc := range buckets {
+		if existing, err := db.DB.Get([]byte(dstKey), nil); err == nil {
+			var prev RollupStat
+			if err := msgpack.Unmarshal(existing, &prev); err == nil {
+				acc.valid += prev.ValidShareCount
+				acc.stale += prev.StaleShareCount
+				acc.invalid += prev.InvalidShareCount
+				acc.hashrateEWMA = hashrateEWMAAlpha*acc.hashrateEWMA + (1-hashrateEWMAAlpha)*prev.HashrateEWMA
+			}
+		}
+
+		data, err := msgpack.Marshal(RollupStat{
+			WorkerName:        acc.worker,
+			ValidShareCount:   acc.valid,
+			StaleShareCount:   acc.stale,
+			InvalidShareCount: acc.invalid,
+			HashrateEWMA:      acc.hashrateEWMA,
+		})
+		if err != nil {
+			log.WithFields(log.Fields{
+				"prefix": "db",
+				"worker": log.WorkerName(acc.worker),
+				"error":  err,
+			}).Error("Failed to marshal rollup bucket")
+			continue
+		}
+		batch.Put([]byte(dstKey), data)
+	}
+
+	for _, key := range foldedKeys {
+		batch.Delete(key)
+	}
+
+	return db.DB.Write(batch, nil)
+}
+
+// pruneTier deletes every row under prefix whose timestamp is older than
+// olderThan, in a single iterate-then-batch-delete pass (the DeleteRange
+// semantics goleveldb doesn't provide natively).
+func (db *Database) pruneTier(prefix string, olderThan int64) error {
+	iter := db.DB.NewIterator(util.BytesPrefix([]byte(prefix)), nil)
+	defer iter.Release()
+
+	batch := new(leveldb.Batch)
+
+	for iter.Next() {
+		key := iter.Key()
+		_, timestamp, err := decodeKey(prefix, key)
+		if err != nil {
+			atomic.AddUint64(&corruptionCount, 1)
+			log.WithFields(log.Fields{
+				"prefix": "db",
+				"key":    string(key),
+				"error":  err,
+			}).Error("Skipping corrupt row while pruning")
+			continue
+		}
+
+		if timestamp < olderThan {
+			batch.Delete(append([]byte(nil), key...))
+		}
+	}
+	if err := iter.Error(); err != nil {
+		return err
+	}
+
+	if batch.Len() == 0 {
+		return nil
+	}
+
+	return db.DB.Write(batch, nil)
+}
+
+// decodeTierValue reads the valid/stale/invalid counts and a hashrate
+// figure out of a row, whatever its concrete (Stat or RollupStat) shape
+// is for that tier.
+func decodeTierValue(srcPrefix string, data []byte) (valid, stale, invalid uint64, hashrate float64, err error) {
+	if srcPrefix == statPrefix {
+		var stat Stat
+		if err = msgpack.Unmarshal(data, &stat); err != nil {
+			return
+		}
+		return stat.ValidShareCount, stat.StaleShareCount, stat.InvalidShareCount, stat.ReportedHashrate, nil
+	}
+
+	var rollup RollupStat
+	if err = msgpack.Unmarshal(data, &rollup); err != nil {
+		return
+	}
+	return rollup.ValidShareCount, rollup.StaleShareCount, rollup.InvalidShareCount, rollup.HashrateEWMA, nil
+}
@@ -0,0 +1,120 @@
+package db
+
+import (
+	"testing"
+
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/storage"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+func newTestDatabase(t *testing.T) *Database {
+	t.Helper()
+	ldb, err := leveldb.Open(storage.NewMemStorage(), nil)
+	if err != nil {
+		t.Fatalf("opening in-memory leveldb: %v", err)
+	}
+	t.Cleanup(func() { ldb.Close() })
+	return &Database{DB: ldb}
+}
+
+// TestRollupTierSumsDeltaRows exercises the bug the maintainer flagged:
+// recordStat writes rows carrying only the delta since its last write,
+// not the connection's cumulative total, so folding several
+// rows from the same bucket must sum to the true total instead of
+// inflating it.
+func TestRollupTierSumsDeltaRows(t *testing.T) {
+	db := newTestDatabase(t)
+
+	const worker = "rig1"
+	const bucketStart = int64(1_000_000)
+
+	batch := new(leveldb.Batch)
+	// Three delta rows landing in the same 1-minute bucket: 1, 1, 3 valid
+	// shares, which should sum to 5, not whatever a cumulative-total
+	// writer would have produced (1, 2, 5 summed to 8).
+	WriteStatToBatch(batch, Stat{WorkerName: worker, ValidShareCount: 1, StaleShareCount: 0, InvalidShareCount: 0}, bucketStart+1)
+	WriteStatToBatch(batch, Stat{WorkerName: worker, ValidShareCount: 1, StaleShareCount: 1, InvalidShareCount: 0}, bucketStart+2)
+	WriteStatToBatch(batch, Stat{WorkerName: worker, ValidShareCount: 3, StaleShareCount: 0, InvalidShareCount: 1}, bucketStart+3)
+	if err := db.DB.Write(batch, nil); err != nil {
+		t.Fatalf("seeding raw stat rows: %v", err)
+	}
+
+	if err := db.rollupTier(statPrefix, rollup1mPrefix, bucket1m, bucketStart+100); err != nil {
+		t.Fatalf("rollupTier: %v", err)
+	}
+
+	dstKey := string(encodeKey(rollup1mPrefix, worker, bucketStart-(bucketStart%bucket1m)))
+	data, err := db.DB.Get([]byte(dstKey), nil)
+	if err != nil {
+		t.Fatalf("reading rolled-up bucket: %v", err)
+	}
+
+	var rollup RollupStat
+	if err := msgpack.Unmarshal(data, &rollup); err != nil {
+		t.Fatalf("unmarshaling rollup bucket: %v", err)
+	}
+
+	if rollup.ValidShareCount != 5 {
+		t.Errorf("ValidShareCount = %d, want 5", rollup.ValidShareCount)
+	}
+	if rollup.StaleShareCount != 1 {
+		t.Errorf("StaleShareCount = %d, want 1", rollup.StaleShareCount)
+	}
+	if rollup.InvalidShareCount != 1 {
+		t.Errorf("InvalidShareCount = %d, want 1", rollup.InvalidShareCount)
+	}
+
+	// The folded raw rows should be gone; a second rollup pass must not
+	// double-count them.
+	iter := db.DB.NewIterator(nil, nil)
+	defer iter.Release()
+	for iter.Next() {
+		if string(iter.Key()) == dstKey {
+			continue
+		}
+		t.Errorf("expected folded raw row to be deleted, found leftover key %q", iter.Key())
+	}
+}
+
+func TestRollupTierLeavesRecentRowsAlone(t *testing.T) {
+	db := newTestDatabase(t)
+
+	batch := new(leveldb.Batch)
+	WriteStatToBatch(batch, Stat{WorkerName: "rig1", ValidShareCount: 1}, 2_000_000)
+	if err := db.DB.Write(batch, nil); err != nil {
+		t.Fatalf("seeding raw stat row: %v", err)
+	}
+
+	// olderThan is before the row's timestamp, so nothing should fold yet.
+	if err := db.rollupTier(statPrefix, rollup1mPrefix, bucket1m, 1_000_000); err != nil {
+		t.Fatalf("rollupTier: %v", err)
+	}
+
+	key := encodeKey(statPrefix, "rig1", 2_000_000)
+	if _, err := db.DB.Get(key, nil); err != nil {
+		t.Fatalf("expected recent raw row to survive rollup, got: %v", err)
+	}
+}
+
+func TestPruneTierDeletesOnlyOldRows(t *testing.T) {
+	db := newTestDatabase(t)
+
+	batch := new(leveldb.Batch)
+	WriteStatToBatch(batch, Stat{WorkerName: "rig1", ValidShareCount: 1}, 100)
+	WriteStatToBatch(batch, Stat{WorkerName: "rig1", ValidShareCount: 1}, 200)
+	if err := db.DB.Write(batch, nil); err != nil {
+		t.Fatalf("seeding raw stat rows: %v", err)
+	}
+
+	if err := db.pruneTier(statPrefix, 150); err != nil {
+		t.Fatalf("pruneTier: %v", err)
+	}
+
+	if _, err := db.DB.Get(encodeKey(statPrefix, "rig1", 100), nil); err == nil {
+		t.Errorf("expected row older than olderThan to be pruned")
+	}
+	if _, err := db.DB.Get(encodeKey(statPrefix, "rig1", 200), nil); err != nil {
+		t.Errorf("expected row newer than olderThan to survive, got: %v", err)
+	}
+}
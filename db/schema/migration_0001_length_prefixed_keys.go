@@ -0,0 +1,112 @@
+package schema
+
+import (
+	"encoding/binary"
+	"strconv"
+	"strings"
+
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/util"
+)
+
+// Migration 1 rewrites stat__ and best__ keys from the original
+// "<prefix><worker>_<timestamp>[_<rand>]" string format to a
+// length-prefixed binary one: <prefix><varint worker name
+// length><worker name bytes><varint timestamp>[<8-byte rand>]. Parsing
+// no longer depends on strings.Split, so a worker name containing an
+// underscore can't be confused with the delimiter anymore, and the
+// worker name is never truncated the way an earlier fixed-width id
+// would have truncated one past a few dozen bytes.
+const (
+	legacyStatPrefix      = "stat__"
+	legacyBestSharePrefix = "best__"
+)
+
+func migrateToLengthPrefixedKeys(db *leveldb.DB) error {
+	batch := new(leveldb.Batch)
+
+	if err := migratePrefix(db, batch, legacyStatPrefix, false); err != nil {
+		return err
+	}
+	if err := migratePrefix(db, batch, legacyBestSharePrefix, true); err != nil {
+		return err
+	}
+
+	if batch.Len() == 0 {
+		return nil
+	}
+
+	return db.Write(batch, nil)
+}
+
+// migratePrefix rewrites every legacy key under prefix. hasRandSuffix is
+// true for best__ rows, whose legacy key has an extra "_<hex rand>"
+// segment after the timestamp to disambiguate multiple best shares
+// landing in the same second.
+func migratePrefix(db *leveldb.DB, batch *leveldb.Batch, prefix string, hasRandSuffix bool) error {
+	iter := db.NewIterator(util.BytesPrefix([]byte(prefix)), nil)
+	defer iter.Release()
+
+	for iter.Next() {
+		key := string(iter.Key())
+		value := append([]byte(nil), iter.Value()...)
+
+		rest := strings.TrimPrefix(key, prefix)
+		parts := strings.Split(rest, "_")
+
+		minParts := 2
+		if hasRandSuffix {
+			minParts = 3
+		}
+		if len(parts) < minParts {
+			// Already migrated, or not in the legacy format; leave it alone.
+			continue
+		}
+
+		var worker, timestampPart, randPart string
+		if hasRandSuffix {
+			worker = strings.Join(parts[:len(parts)-2], "_")
+			timestampPart = parts[len(parts)-2]
+			randPart = parts[len(parts)-1]
+		} else {
+			worker = strings.Join(parts[:len(parts)-1], "_")
+			timestampPart = parts[len(parts)-1]
+		}
+
+		timestamp, err := strconv.ParseInt(timestampPart, 10, 64)
+		if err != nil {
+			continue
+		}
+
+		newKey := encodeLengthPrefixedKey(prefix, worker, timestamp)
+		if hasRandSuffix {
+			randValue, err := strconv.ParseUint(randPart, 16, 64)
+			if err != nil {
+				continue
+			}
+			randBytes := make([]byte, 8)
+			binary.BigEndian.PutUint64(randBytes, randValue)
+			newKey = append(newKey, randBytes...)
+		}
+
+		batch.Delete([]byte(key))
+		batch.Put(newKey, value)
+	}
+
+	return iter.Error()
+}
+
+func encodeLengthPrefixedKey(prefix, workerName string, timestamp int64) []byte {
+	nameLenBuf := make([]byte, binary.MaxVarintLen64)
+	nameLenN := binary.PutUvarint(nameLenBuf, uint64(len(workerName)))
+
+	tsBuf := make([]byte, binary.MaxVarintLen64)
+	tsN := binary.PutVarint(tsBuf, timestamp)
+
+	key := make([]byte, 0, len(prefix)+nameLenN+len(workerName)+tsN)
+	key = append(key, prefix...)
+	key = append(key, nameLenBuf[:nameLenN]...)
+	key = append(key, workerName...)
+	key = append(key, tsBuf[:tsN]...)
+	return key
+}
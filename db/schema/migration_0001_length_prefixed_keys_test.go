@@ -0,0 +1,79 @@
+package schema
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+func TestMigrateToLengthPrefixedKeysRewritesStatRows(t *testing.T) {
+	ldb := newTestDB(t)
+
+	legacyKey := []byte(legacyStatPrefix + "rig_1_1700000000")
+	if err := ldb.Put(legacyKey, []byte("payload"), nil); err != nil {
+		t.Fatalf("seeding legacy row: %v", err)
+	}
+
+	if err := migrateToLengthPrefixedKeys(ldb); err != nil {
+		t.Fatalf("migrateToLengthPrefixedKeys: %v", err)
+	}
+
+	if _, err := ldb.Get(legacyKey, nil); err == nil {
+		t.Fatalf("expected legacy key to be deleted")
+	}
+
+	newKey := encodeLengthPrefixedKey(legacyStatPrefix, "rig_1", 1700000000)
+	value, err := ldb.Get(newKey, nil)
+	if err != nil {
+		t.Fatalf("expected rewritten key to exist: %v", err)
+	}
+	if string(value) != "payload" {
+		t.Errorf("value = %q, want %q", value, "payload")
+	}
+}
+
+func TestMigrateToLengthPrefixedKeysRewritesBestShareRandSuffix(t *testing.T) {
+	ldb := newTestDB(t)
+
+	legacyKey := []byte(legacyBestSharePrefix + "rig1_1700000000_deadbeef")
+	if err := ldb.Put(legacyKey, []byte("payload"), nil); err != nil {
+		t.Fatalf("seeding legacy row: %v", err)
+	}
+
+	if err := migrateToLengthPrefixedKeys(ldb); err != nil {
+		t.Fatalf("migrateToLengthPrefixedKeys: %v", err)
+	}
+
+	newKey := encodeLengthPrefixedKey(legacyBestSharePrefix, "rig1", 1700000000)
+	randBytes := make([]byte, 8)
+	binary.BigEndian.PutUint64(randBytes, 0xdeadbeef)
+	newKey = append(newKey, randBytes...)
+
+	value, err := ldb.Get(newKey, nil)
+	if err != nil {
+		t.Fatalf("expected rewritten key to exist: %v", err)
+	}
+	if string(value) != "payload" {
+		t.Errorf("value = %q, want %q", value, "payload")
+	}
+}
+
+func TestMigrateToLengthPrefixedKeysLeavesAlreadyMigratedRowsAlone(t *testing.T) {
+	ldb := newTestDB(t)
+
+	newKey := encodeLengthPrefixedKey(legacyStatPrefix, "rig1", 1700000000)
+	if err := ldb.Put(newKey, []byte("payload"), nil); err != nil {
+		t.Fatalf("seeding already-migrated row: %v", err)
+	}
+
+	if err := migrateToLengthPrefixedKeys(ldb); err != nil {
+		t.Fatalf("migrateToLengthPrefixedKeys: %v", err)
+	}
+
+	value, err := ldb.Get(newKey, nil)
+	if err != nil {
+		t.Fatalf("expected already-migrated row to survive untouched: %v", err)
+	}
+	if string(value) != "payload" {
+		t.Errorf("value = %q, want %q", value, "payload")
+	}
+}
@@ -0,0 +1,81 @@
+// Package schema manages solo's on-disk LevelDB layout: a version key
+// at a fixed spot in the keyspace, and an ordered list of migrations
+// that walk a database up to the version this binary understands.
+//
+// Migrations are immutable history: once committed, a migration's
+// encode/decode logic must never change, even if the "live" encoding
+// used elsewhere in the db package moves on in a later migration. For
+// that reason migrations intentionally don't import the db package;
+// each one inlines exactly the byte layouts it reads and writes.
+package schema
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/syndtr/goleveldb/leveldb"
+)
+
+// versionKey holds the schema version as a big-endian uint32. The 0x00
+// lead byte sorts before every ASCII key prefix used elsewhere, so it's
+// always the first key in the database.
+var versionKey = []byte{0x00, 'v', 'e', 'r', 's', 'i', 'o', 'n'}
+
+// Migration upgrades a database from the version immediately below its
+// index in Migrations to the version at its index: Migrations[0] takes
+// a database from version 0 to version 1, and so on.
+type Migration func(db *leveldb.DB) error
+
+// Migrations is the ordered, append-only list of schema migrations.
+var Migrations = []Migration{
+	migrateToLengthPrefixedKeys,
+}
+
+// CurrentVersion is the schema version this binary reads and writes.
+var CurrentVersion = uint32(len(Migrations))
+
+// Version reads the schema version stored in db, returning 0 for a
+// database that predates versioning entirely.
+func Version(db *leveldb.DB) (uint32, error) {
+	data, err := db.Get(versionKey, nil)
+	if err == leveldb.ErrNotFound {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return binary.BigEndian.Uint32(data), nil
+}
+
+func setVersion(db *leveldb.DB, v uint32) error {
+	data := make([]byte, 4)
+	binary.BigEndian.PutUint32(data, v)
+	return db.Put(versionKey, data, nil)
+}
+
+// Migrate brings db up to CurrentVersion, running any pending
+// migrations in order. It refuses to touch a database whose stored
+// version is newer than CurrentVersion, since that means this binary is
+// older than the one that last wrote it.
+func Migrate(db *leveldb.DB) error {
+	version, err := Version(db)
+	if err != nil {
+		return err
+	}
+
+	if version > CurrentVersion {
+		return fmt.Errorf("database schema v%d is newer than this binary supports (v%d)", version, CurrentVersion)
+	}
+
+	for _, migrate := range Migrations[version:] {
+		if err := migrate(db); err != nil {
+			return fmt.Errorf("migrating schema from v%d: %w", version, err)
+		}
+		version++
+		if err := setVersion(db, version); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
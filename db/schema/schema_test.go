@@ -0,0 +1,78 @@
+package schema
+
+import (
+	"testing"
+
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/storage"
+)
+
+func newTestDB(t *testing.T) *leveldb.DB {
+	t.Helper()
+	ldb, err := leveldb.Open(storage.NewMemStorage(), nil)
+	if err != nil {
+		t.Fatalf("opening in-memory leveldb: %v", err)
+	}
+	t.Cleanup(func() { ldb.Close() })
+	return ldb
+}
+
+func TestVersionDefaultsToZero(t *testing.T) {
+	ldb := newTestDB(t)
+
+	v, err := Version(ldb)
+	if err != nil {
+		t.Fatalf("Version: %v", err)
+	}
+	if v != 0 {
+		t.Errorf("Version() = %d, want 0 for a database that predates versioning", v)
+	}
+}
+
+func TestMigrateRunsEachMigrationOnceAndPersistsVersion(t *testing.T) {
+	ldb := newTestDB(t)
+
+	var calls int
+	restore := Migrations
+	Migrations = []Migration{
+		func(db *leveldb.DB) error { calls++; return nil },
+	}
+	defer func() { Migrations = restore }()
+	CurrentVersion = uint32(len(Migrations))
+
+	if err := Migrate(ldb); err != nil {
+		t.Fatalf("Migrate: %v", err)
+	}
+	if calls != 1 {
+		t.Fatalf("migration ran %d times, want 1", calls)
+	}
+
+	v, err := Version(ldb)
+	if err != nil {
+		t.Fatalf("Version: %v", err)
+	}
+	if v != CurrentVersion {
+		t.Errorf("stored version = %d, want %d", v, CurrentVersion)
+	}
+
+	// A second Migrate call against an already-migrated database must not
+	// re-run any migration.
+	if err := Migrate(ldb); err != nil {
+		t.Fatalf("second Migrate: %v", err)
+	}
+	if calls != 1 {
+		t.Errorf("migration re-ran on an up-to-date database: %d calls, want 1", calls)
+	}
+}
+
+func TestMigrateRefusesNewerStoredVersion(t *testing.T) {
+	ldb := newTestDB(t)
+
+	if err := setVersion(ldb, 99); err != nil {
+		t.Fatalf("setVersion: %v", err)
+	}
+
+	if err := Migrate(ldb); err == nil {
+		t.Fatalf("expected Migrate to refuse a database newer than CurrentVersion")
+	}
+}
@@ -2,12 +2,11 @@ package db
 
 import (
 	"math/rand"
-	"strconv"
-	"strings"
+	"sync/atomic"
 	"time"
 
+	"github.com/flexpool/solo/log"
 	"github.com/syndtr/goleveldb/leveldb"
-	"github.com/syndtr/goleveldb/leveldb/util"
 	"github.com/vmihailenco/msgpack/v5"
 )
 
@@ -33,34 +32,68 @@ type BestShare struct {
 
 // WriteStatToBatch writes worker stat object to the LevelDB batch
 func WriteStatToBatch(batch *leveldb.Batch, stat Stat, timestamp int64) {
-	data, _ := msgpack.Marshal(stat)
-	key := statPrefix + stat.WorkerName + "_" + strconv.FormatInt(timestamp, 10)
-	batch.Put([]byte(key), data)
+	data, err := msgpack.Marshal(stat)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"prefix": "db",
+			"worker": log.WorkerName(stat.WorkerName),
+			"error":  err,
+		}).Error("Failed to marshal stat")
+		return
+	}
+	batch.Put(encodeKey(statPrefix, stat.WorkerName, timestamp), data)
 }
 
 // WriteBestShareToBatch writes best share object to the LevelDB batch
 func WriteBestShareToBatch(batch *leveldb.Batch, bestShare BestShare, timestamp int64) {
-	data, _ := msgpack.Marshal(bestShare)
-	key := bestSharePrefix + bestShare.WorkerName + "_" + strconv.FormatInt(timestamp, 10) + "_" + strconv.FormatUint(rand.Uint64(), 16)
-	batch.Put([]byte(key), data)
+	data, err := msgpack.Marshal(bestShare)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"prefix": "db",
+			"worker": log.WorkerName(bestShare.WorkerName),
+			"error":  err,
+		}).Error("Failed to marshal best share")
+		return
+	}
+	batch.Put(encodeBestShareKey(bestShare.WorkerName, timestamp, rand.Uint64()), data)
 }
 
-// PruneStats removes data older than
-func (db *Database) PruneStats(deleteDataOlderThanSecs int64) {
-	iter := db.DB.NewIterator(util.BytesPrefix([]byte(statPrefix)), nil)
+// corruptionCount tracks raw and rollup rows that couldn't be parsed
+// while pruning or rolling up, so operators can see corruption happening
+// instead of the process crashing on it.
+var corruptionCount uint64
 
-	deleteWithTimestampLowerThan := time.Now().Unix() - deleteDataOlderThanSecs
+// CorruptionCount returns the number of rows PruneStats has skipped
+// because their key couldn't be parsed, plus those Rollup has skipped
+// because their key or value couldn't be parsed.
+func CorruptionCount() uint64 {
+	return atomic.LoadUint64(&corruptionCount)
+}
 
-	for iter.Next() {
-		key := iter.Key()
-		keySplitted := strings.Split(string(key), "_")
-		timestamp, err := strconv.ParseInt(keySplitted[len(keySplitted)-1], 10, 64)
-		if err != nil {
-			panic("Database corruption")
-		}
+// PruneStats removes data older than deleteDataOlderThanSecs from the
+// raw stat tier, plus anything past its own retention in each rollup
+// tier (see Rollup). Every tier is pruned in a single iterate-then-batch
+// pass rather than one Delete call per key.
+func (db *Database) PruneStats(deleteDataOlderThanSecs int64) {
+	now := time.Now().Unix()
+
+	tiers := []struct {
+		prefix    string
+		olderThan int64
+	}{
+		{statPrefix, now - deleteDataOlderThanSecs},
+		{rollup1mPrefix, now - rollup1mRetention},
+		{rollup1hPrefix, now - rollup1hRetention},
+		{rollup1dPrefix, now - rollup1dRetention},
+	}
 
-		if timestamp < deleteWithTimestampLowerThan {
-			db.DB.Delete(key, nil)
+	for _, t := range tiers {
+		if err := db.pruneTier(t.prefix, t.olderThan); err != nil {
+			log.WithFields(log.Fields{
+				"prefix": "db",
+				"tier":   t.prefix,
+				"error":  err,
+			}).Error("Failed to prune tier")
 		}
 	}
-}
\ No newline at end of file
+}
@@ -0,0 +1,16 @@
+package db
+
+import (
+	"time"
+
+	"github.com/syndtr/goleveldb/leveldb"
+)
+
+// WriteStat writes a single Stat record to the database immediately,
+// for callers (such as the Stratum server) that update a worker's
+// counters one share at a time rather than batching writes themselves.
+func (db *Database) WriteStat(stat Stat) error {
+	batch := new(leveldb.Batch)
+	WriteStatToBatch(batch, stat, time.Now().Unix())
+	return db.DB.Write(batch, nil)
+}
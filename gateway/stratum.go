@@ -0,0 +1,438 @@
+package gateway
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/flexpool/solo/db"
+	"github.com/flexpool/solo/log"
+)
+
+// ErrStratumV2Unsupported is returned by StratumServer.RunV2 until binary
+// Stratum v2 framing is implemented; for now only the legacy
+// line-delimited JSON-RPC protocol is served.
+var ErrStratumV2Unsupported = errors.New("stratum v2 binary framing is not yet implemented")
+
+// stratumRequest is a Stratum v1 (mining.*) line-delimited JSON-RPC request.
+type stratumRequest struct {
+	ID     interface{}       `json:"id"`
+	Method string            `json:"method"`
+	Params []json.RawMessage `json:"params"`
+}
+
+// stratumResponse is a reply to a stratumRequest, or an unsolicited
+// server-initiated notification (ID is nil for those).
+type stratumResponse struct {
+	ID     interface{} `json:"id"`
+	Method string      `json:"method,omitempty"`
+	Params interface{} `json:"params,omitempty"`
+	Result interface{} `json:"result,omitempty"`
+	Error  interface{} `json:"error"`
+}
+
+// stratumConn tracks one miner connection to the Stratum server, in place
+// of the bare `chan []string` the old internal-only subscription
+// mechanism used.
+type stratumConn struct {
+	conn       net.Conn
+	enc        *json.Encoder
+	encMux     sync.Mutex
+	workerName string
+	ip         string
+	diff       uint64
+	work       chan []string
+	subID      uint64
+	subscribed bool
+
+	validShares   uint64
+	staleShares   uint64
+	invalidShares uint64
+
+	// reported{Valid,Stale,Invalid}Shares and lastStatWrite track what's
+	// already been persisted via recordStat, so each write carries only
+	// the delta since the last one instead of the connection's running
+	// total (see recordStat). Both are only ever touched from the
+	// connection's single read goroutine, so they need no locking.
+	reportedValidShares   uint64
+	reportedStaleShares   uint64
+	reportedInvalidShares uint64
+	lastStatWrite         time.Time
+}
+
+func (c *stratumConn) send(resp stratumResponse) error {
+	c.encMux.Lock()
+	defer c.encMux.Unlock()
+	return c.enc.Encode(resp)
+}
+
+// StratumServer is a Stratum front-end for WorkManager: it lets standard
+// miners connect directly over `mining.subscribe`/`mining.notify`/
+// `mining.submit`, instead of requiring an external stratum proxy in
+// front of the OpenEthereum-style getWork push.
+type StratumServer struct {
+	bind        string
+	listener    net.Listener
+	workManager *WorkManager
+	db          *db.Database
+
+	connsMux sync.Mutex
+	conns    map[*stratumConn]struct{}
+}
+
+// NewStratumServer creates a Stratum server that will dispatch work from
+// wm and, if database is non-nil, persist accepted/stale/invalid counts
+// to the worker's db.Stat record.
+func NewStratumServer(bind string, wm *WorkManager, database *db.Database) *StratumServer {
+	return &StratumServer{
+		bind:        bind,
+		workManager: wm,
+		db:          database,
+		conns:       make(map[*stratumConn]struct{}),
+	}
+}
+
+// Run starts accepting Stratum v1 connections. It blocks until the
+// listener is closed via Stop.
+func (s *StratumServer) Run() error {
+	listener, err := net.Listen("tcp", s.bind)
+	if err != nil {
+		return err
+	}
+	s.listener = listener
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return err
+		}
+		go s.handleConn(conn)
+	}
+}
+
+// RunV2 would serve binary Stratum v2 framing on bind, but that framing
+// isn't implemented yet.
+func (s *StratumServer) RunV2(bind string) error {
+	return ErrStratumV2Unsupported
+}
+
+// Stop closes the listener and disconnects every miner.
+func (s *StratumServer) Stop() error {
+	var err error
+	if s.listener != nil {
+		err = s.listener.Close()
+	}
+
+	s.connsMux.Lock()
+	for c := range s.conns {
+		c.conn.Close()
+	}
+	s.connsMux.Unlock()
+
+	return err
+}
+
+func (s *StratumServer) handleConn(conn net.Conn) {
+	host, _, _ := net.SplitHostPort(conn.RemoteAddr().String())
+
+	c := &stratumConn{
+		conn: conn,
+		enc:  json.NewEncoder(conn),
+		ip:   host,
+		diff: s.workManager.shareDiff,
+		work: make(chan []string, subscriberQueueSize),
+	}
+
+	s.connsMux.Lock()
+	s.conns[c] = struct{}{}
+	s.connsMux.Unlock()
+
+	defer func() {
+		s.connsMux.Lock()
+		delete(s.conns, c)
+		s.connsMux.Unlock()
+		if c.subscribed {
+			s.workManager.UnsubscribeNotifications(c.subID)
+		}
+		s.recordStat(c, true)
+		conn.Close()
+	}()
+
+	go s.notifyLoop(c)
+
+	scanner := bufio.NewScanner(conn)
+	for scanner.Scan() {
+		var req stratumRequest
+		if err := json.Unmarshal(scanner.Bytes(), &req); err != nil {
+			log.WithFields(log.Fields{
+				"prefix": "stratum",
+				"ip":     log.IP(c.ip),
+				"error":  err,
+			}).Warn("Failed to parse Stratum request")
+			continue
+		}
+
+		s.handleRequest(c, req)
+	}
+}
+
+func (s *StratumServer) handleRequest(c *stratumConn, req stratumRequest) {
+	switch req.Method {
+	case "mining.subscribe":
+		c.send(stratumResponse{
+			ID:     req.ID,
+			Result: []interface{}{[]string{"mining.notify", "solo"}, ""},
+		})
+
+	case "mining.authorize":
+		var params []string
+		if err := unmarshalParams(req.Params, &params); err != nil || len(params) == 0 {
+			c.send(stratumResponse{ID: req.ID, Result: false, Error: "invalid params"})
+			return
+		}
+		c.workerName = params[0]
+
+		c.send(stratumResponse{ID: req.ID, Result: true})
+		c.send(stratumResponse{
+			ID:     nil,
+			Method: "mining.set_difficulty",
+			Params: []interface{}{c.diff},
+		})
+		c.subID = s.workManager.SubscribeNotifications(c.work)
+		c.subscribed = true
+		if last := s.workManager.currentWork(); last[0] != "0x0" {
+			c.work <- last
+		}
+
+	case "mining.submit":
+		s.handleSubmit(c, req)
+
+	default:
+		c.send(stratumResponse{ID: req.ID, Error: fmt.Sprintf("unknown method %q", req.Method)})
+	}
+}
+
+func (s *StratumServer) handleSubmit(c *stratumConn, req stratumRequest) {
+	var params []string
+	if err := unmarshalParams(req.Params, &params); err != nil || len(params) < 4 {
+		c.send(stratumResponse{ID: req.ID, Result: false, Error: "invalid params"})
+		return
+	}
+
+	workerName, headerHash, nonce, mixDigest := params[0], params[1], params[2], params[3]
+
+	valid, stale, blockCandidate, err := s.verifyShare(headerHash, nonce, mixDigest)
+	accepted := err == nil && valid && !stale
+
+	switch {
+	case accepted:
+		atomic.AddUint64(&c.validShares, 1)
+	case err == nil && stale:
+		atomic.AddUint64(&c.staleShares, 1)
+	default:
+		atomic.AddUint64(&c.invalidShares, 1)
+	}
+
+	c.send(stratumResponse{ID: req.ID, Result: accepted})
+
+	log.WithFields(log.Fields{
+		"prefix":      "stratum",
+		"worker":      log.WorkerName(workerName),
+		"ip":          log.IP(c.ip),
+		"header-hash": log.Hash(headerHash),
+		"accepted":    accepted,
+		"stale":       stale,
+	}).Info("Share submitted")
+
+	if blockCandidate {
+		found, submitErr := s.workManager.SubmitWork(nonce, headerHash, mixDigest)
+		fields := log.Fields{
+			"prefix":      "stratum",
+			"worker":      log.WorkerName(workerName),
+			"header-hash": log.Hash(headerHash),
+			"found":       found,
+		}
+		if submitErr != nil {
+			fields["error"] = submitErr
+		}
+		log.WithFields(fields).Info("Forwarded block candidate to node")
+	}
+
+	s.recordStat(c, false)
+}
+
+// verifyShare checks a submitted (headerHash, nonce, mixDigest) triple
+// against the job it claims to solve. known (folded into valid/stale
+// below) reports whether headerHash matches a job this server actually
+// handed out; stale reports whether that job has since been superseded
+// by a newer one; valid reports whether the resulting hash meets the
+// pool's own share difficulty; blockCandidate additionally reports
+// whether it meets the network's difficulty, meaning it's also a
+// candidate block solution. This recomputes ethash's final keccak step
+// (seed = Keccak512(headerHash || nonce as an 8-byte little-endian
+// uint64), result = Keccak256(seed || mixDigest)), which is cheap to
+// redo locally, but not the hashimoto dataset/DAG lookups that produced
+// mixDigest in the first place, so a block candidate is only ever
+// authoritatively confirmed node-side once it's forwarded on via
+// SubmitWork.
+func (s *StratumServer) verifyShare(headerHash, nonce, mixDigest string) (valid, stale, blockCandidate bool, err error) {
+	work, known := s.workManager.workHistory.Get(headerHash)
+	if !known {
+		return false, false, false, nil
+	}
+	stale = work[0] != s.workManager.currentWork()[0]
+
+	headerBytes, err := hexToBytes(headerHash)
+	if err != nil {
+		return false, stale, false, err
+	}
+	nonceVal, err := hexToUint64(nonce)
+	if err != nil {
+		return false, stale, false, err
+	}
+	mixBytes, err := hexToBytes(mixDigest)
+	if err != nil {
+		return false, stale, false, err
+	}
+
+	nonceBytes := make([]byte, 8)
+	binary.LittleEndian.PutUint64(nonceBytes, nonceVal)
+
+	seed := crypto.Keccak512(headerBytes, nonceBytes)
+	result := crypto.Keccak256(seed, mixBytes)
+	resultInt := new(big.Int).SetBytes(result)
+
+	valid = resultInt.Cmp(s.workManager.shareTargetBigInt) <= 0
+
+	networkTargetBytes, err := hexToBytes(work[2])
+	if err != nil {
+		return valid, stale, false, nil
+	}
+	networkTarget := new(big.Int).SetBytes(networkTargetBytes)
+	blockCandidate = valid && resultInt.Cmp(networkTarget) <= 0
+
+	return valid, stale, blockCandidate, nil
+}
+
+// hexToBytes decodes a "0x"-prefixed (or bare) hex string, tolerating an
+// odd number of digits the way a leading-zero-trimmed big.Int hex string
+// often has.
+func hexToBytes(s string) ([]byte, error) {
+	s = strings.TrimPrefix(s, "0x")
+	if len(s)%2 != 0 {
+		s = "0" + s
+	}
+	return hex.DecodeString(s)
+}
+
+// hexToUint64 parses a "0x"-prefixed (or bare) hex string as an ethash
+// nonce, which the Stratum wire format carries as a hex-encoded integer
+// rather than a fixed-width byte string.
+func hexToUint64(s string) (uint64, error) {
+	s = strings.TrimPrefix(s, "0x")
+	if s == "" {
+		return 0, nil
+	}
+	return strconv.ParseUint(s, 16, 64)
+}
+
+func (s *StratumServer) notifyLoop(c *stratumConn) {
+	for work := range c.work {
+		params := make([]interface{}, len(work))
+		for i, v := range work {
+			params[i] = v
+		}
+		params[2] = "0x" + bigToPaddedHex(s.workManager.shareTargetBigInt)
+
+		if err := c.send(stratumResponse{ID: nil, Method: "mining.notify", Params: params}); err != nil {
+			return
+		}
+	}
+}
+
+// statFlushInterval throttles how often recordStat persists a row per
+// connection: c.validShares/staleShares/invalidShares are cumulative
+// connection-lifetime totals, so every share submitted would otherwise
+// produce its own LevelDB write.
+const statFlushInterval = 10 * time.Second
+
+// recordStat persists the share counts accumulated since the last
+// persisted row, as a delta rather than the connection's running total
+// (Rollup sums every raw row it folds into a bucket, so a row carrying
+// the cumulative total would be counted again on every subsequent
+// write). A write is skipped if statFlushInterval hasn't elapsed since
+// the last one, unless force is set, which handleConn does on disconnect
+// so a connection's final shares aren't lost.
+func (s *StratumServer) recordStat(c *stratumConn, force bool) {
+	if s.db == nil {
+		return
+	}
+
+	now := time.Now()
+	if !force && !c.lastStatWrite.IsZero() && now.Sub(c.lastStatWrite) < statFlushInterval {
+		return
+	}
+
+	valid := atomic.LoadUint64(&c.validShares)
+	stale := atomic.LoadUint64(&c.staleShares)
+	invalid := atomic.LoadUint64(&c.invalidShares)
+
+	validDelta := valid - c.reportedValidShares
+	staleDelta := stale - c.reportedStaleShares
+	invalidDelta := invalid - c.reportedInvalidShares
+	if validDelta == 0 && staleDelta == 0 && invalidDelta == 0 {
+		return
+	}
+
+	stat := db.Stat{
+		WorkerName:        c.workerName,
+		ValidShareCount:   validDelta,
+		StaleShareCount:   staleDelta,
+		InvalidShareCount: invalidDelta,
+		IPAddress:         c.ip,
+	}
+
+	if err := s.db.WriteStat(stat); err != nil {
+		log.WithFields(log.Fields{
+			"prefix": "stratum",
+			"worker": log.WorkerName(c.workerName),
+			"error":  err,
+		}).Error("Failed to persist share stats")
+		return
+	}
+
+	c.reportedValidShares, c.reportedStaleShares, c.reportedInvalidShares = valid, stale, invalid
+	c.lastStatWrite = now
+}
+
+func unmarshalParams(raw []json.RawMessage, out *[]string) error {
+	result := make([]string, len(raw))
+	for i, p := range raw {
+		var s string
+		if err := json.Unmarshal(p, &s); err != nil {
+			var n float64
+			if err2 := json.Unmarshal(p, &n); err2 != nil {
+				return err
+			}
+			s = strconv.FormatFloat(n, 'f', -1, 64)
+		}
+		result[i] = s
+	}
+	*out = result
+	return nil
+}
+
+func bigToPaddedHex(n *big.Int) string {
+	return fmt.Sprintf("%064x", n)
+}
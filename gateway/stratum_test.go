@@ -0,0 +1,108 @@
+package gateway
+
+import (
+	"math/big"
+	"strings"
+	"testing"
+)
+
+func newStratumServerForTest() (*StratumServer, *WorkManager) {
+	wm := NewWorkManager("127.0.0.1:0", 1, nil)
+	s := NewStratumServer("127.0.0.1:0", wm, nil)
+	return s, wm
+}
+
+// maxUint256Hex is 2^256-1 (64 hex digits), computed rather than
+// hand-typed so a miscounted literal can't silently understate it.
+var maxUint256Hex = strings.Repeat("f", 64)
+
+func maxUint256() *big.Int {
+	n := new(big.Int)
+	n.SetString(maxUint256Hex, 16)
+	return n
+}
+
+func TestVerifyShareUnknownHeaderHash(t *testing.T) {
+	s, _ := newStratumServerForTest()
+
+	valid, stale, blockCandidate, err := s.verifyShare("0xaaaa", "0x1", "0xdeadbeef")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if valid || stale || blockCandidate {
+		t.Fatalf("valid=%v stale=%v blockCandidate=%v, want all false for an unknown job", valid, stale, blockCandidate)
+	}
+}
+
+func TestVerifyShareMalformedNonce(t *testing.T) {
+	s, wm := newStratumServerForTest()
+	wm.handleNewWork([]string{"0xaaaa", "0xcccc", "0xdddd", "0x1"})
+
+	if _, _, _, err := s.verifyShare("0xaaaa", "not-hex", "0xdeadbeef"); err == nil {
+		t.Fatalf("expected an error for a malformed nonce")
+	}
+}
+
+// TestVerifyShareAcceptsAgainstAMaximalShareTarget exercises verifyShare's
+// wiring (job lookup, hex decoding, hash comparison) without attempting
+// real Ethash mining: a share target of 2^256-1 accepts any hash.
+func TestVerifyShareAcceptsAgainstAMaximalShareTarget(t *testing.T) {
+	s, wm := newStratumServerForTest()
+	s.workManager.shareTargetBigInt = maxUint256()
+	wm.handleNewWork([]string{"0xaaaa", "0xcccc", "0xdddd", "0x1"})
+
+	valid, stale, _, err := s.verifyShare("0xaaaa", "0x1", "0xdeadbeef")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !valid {
+		t.Fatalf("expected any hash to satisfy a maximal share target")
+	}
+	if stale {
+		t.Fatalf("freshly dispatched job should not be stale")
+	}
+}
+
+func TestVerifyShareRejectsAgainstAZeroShareTarget(t *testing.T) {
+	s, wm := newStratumServerForTest()
+	s.workManager.shareTargetBigInt = big.NewInt(0)
+	wm.handleNewWork([]string{"0xaaaa", "0xcccc", "0xdddd", "0x1"})
+
+	valid, _, _, err := s.verifyShare("0xaaaa", "0x1", "0xdeadbeef")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if valid {
+		t.Fatalf("expected no hash to satisfy a zero share target")
+	}
+}
+
+func TestVerifyShareReportsStaleJobs(t *testing.T) {
+	s, wm := newStratumServerForTest()
+	s.workManager.shareTargetBigInt = maxUint256()
+
+	wm.handleNewWork([]string{"0xaaaa", "0xcccc", "0xdddd", "0x1"})
+	wm.handleNewWork([]string{"0xbbbb", "0xcccc", "0xdddd", "0x2"})
+
+	_, stale, _, err := s.verifyShare("0xaaaa", "0x1", "0xdeadbeef")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !stale {
+		t.Fatalf("expected a superseded job to be reported stale")
+	}
+}
+
+func TestVerifyShareReportsBlockCandidates(t *testing.T) {
+	s, wm := newStratumServerForTest()
+	s.workManager.shareTargetBigInt = maxUint256()
+	wm.handleNewWork([]string{"0xaaaa", "0xcccc", "0x" + maxUint256Hex, "0x1"})
+
+	_, _, blockCandidate, err := s.verifyShare("0xaaaa", "0x1", "0xdeadbeef")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !blockCandidate {
+		t.Fatalf("expected a share meeting the maximal network target to be a block candidate")
+	}
+}
@@ -0,0 +1,102 @@
+package gateway
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// subscriberShards controls how many independent locks guard the
+// subscriber set. Splitting subscribers across shards means dispatching
+// a new job to one shard never blocks lookups/registration on another,
+// and a lock held briefly while iterating one shard only contends with
+// the (1/subscriberShards) of subscribers that hash into it.
+const subscriberShards = 16
+
+// subscriberQueueSize is the depth of each subscriber's notification
+// channel. Dispatch never blocks on a slow subscriber: once its queue is
+// full, the oldest queued job is dropped to make room for the new one,
+// since only the latest job is ever worth mining on anyway.
+const subscriberQueueSize = 4
+
+type subscriberShard struct {
+	mux   sync.Mutex
+	conns map[uint64]chan []string
+}
+
+// subscriberSet is a sharded replacement for the old `[]chan []string` +
+// single mutex. It also replaces the `isChanClosed` dispatch trick
+// (which could read, and thereby consume, a real pending job while
+// probing whether a channel was closed) with explicit Subscribe/
+// Unsubscribe calls.
+type subscriberSet struct {
+	shards [subscriberShards]*subscriberShard
+	nextID uint64
+}
+
+func newSubscriberSet() *subscriberSet {
+	s := &subscriberSet{}
+	for i := range s.shards {
+		s.shards[i] = &subscriberShard{conns: make(map[uint64]chan []string)}
+	}
+	return s
+}
+
+func (s *subscriberSet) shardFor(id uint64) *subscriberShard {
+	return s.shards[id%subscriberShards]
+}
+
+// Add registers ch and returns a subscription id for later Remove. id
+// allocation uses atomic.AddUint64 rather than a plain increment because
+// Add is called concurrently from every Stratum connection's goroutine;
+// a racing increment could hand out the same id twice, and the second
+// Add would silently overwrite the first subscriber's channel.
+func (s *subscriberSet) Add(ch chan []string) uint64 {
+	id := atomic.AddUint64(&s.nextID, 1)
+	shard := s.shardFor(id)
+
+	shard.mux.Lock()
+	shard.conns[id] = ch
+	shard.mux.Unlock()
+
+	return id
+}
+
+// Remove unregisters a subscription added via Add.
+func (s *subscriberSet) Remove(id uint64) {
+	shard := s.shardFor(id)
+
+	shard.mux.Lock()
+	delete(shard.conns, id)
+	shard.mux.Unlock()
+}
+
+// Dispatch fans work out to every subscriber. Each send is non-blocking;
+// a subscriber whose queue is full has its oldest queued job dropped to
+// make room, so one slow miner can never stall delivery to the rest.
+func (s *subscriberSet) Dispatch(work []string) {
+	for _, shard := range s.shards {
+		shard.mux.Lock()
+		for _, ch := range shard.conns {
+			dispatchDropOldest(ch, work)
+		}
+		shard.mux.Unlock()
+	}
+}
+
+func dispatchDropOldest(ch chan []string, work []string) {
+	select {
+	case ch <- work:
+		return
+	default:
+	}
+
+	select {
+	case <-ch:
+	default:
+	}
+
+	select {
+	case ch <- work:
+	default:
+	}
+}
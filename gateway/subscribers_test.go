@@ -0,0 +1,86 @@
+package gateway
+
+import (
+	"sync"
+	"testing"
+)
+
+// TestSubscriberSetAddIsConcurrencySafe reproduces the race where
+// unguarded nextID allocation could hand two concurrent Adds the same
+// id, silently overwriting one subscriber's channel with another's.
+func TestSubscriberSetAddIsConcurrencySafe(t *testing.T) {
+	s := newSubscriberSet()
+
+	const n = 200
+	ids := make([]uint64, n)
+	chans := make([]chan []string, n)
+
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		chans[i] = make(chan []string, subscriberQueueSize)
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			ids[i] = s.Add(chans[i])
+		}(i)
+	}
+	wg.Wait()
+
+	seen := make(map[uint64]bool, n)
+	for _, id := range ids {
+		if seen[id] {
+			t.Fatalf("duplicate subscription id %d handed out", id)
+		}
+		seen[id] = true
+	}
+
+	total := 0
+	for _, shard := range s.shards {
+		total += len(shard.conns)
+	}
+	if total != n {
+		t.Fatalf("expected %d registered subscribers, got %d", n, total)
+	}
+}
+
+func TestSubscriberSetDispatchDropsOldest(t *testing.T) {
+	s := newSubscriberSet()
+	ch := make(chan []string, subscriberQueueSize)
+	s.Add(ch)
+
+	for i := 0; i < subscriberQueueSize+2; i++ {
+		s.Dispatch([]string{"0xwork", string(rune('a' + i))})
+	}
+
+	if got := len(ch); got != subscriberQueueSize {
+		t.Fatalf("channel length = %d, want %d (queue should be full but not overflow)", got, subscriberQueueSize)
+	}
+
+	var last []string
+	for {
+		select {
+		case last = <-ch:
+			continue
+		default:
+		}
+		break
+	}
+	if last[1] != string(rune('a'+subscriberQueueSize+1)) {
+		t.Fatalf("expected the most recent job to survive drop-oldest, got %v", last)
+	}
+}
+
+func TestSubscriberSetRemove(t *testing.T) {
+	s := newSubscriberSet()
+	ch := make(chan []string, subscriberQueueSize)
+	id := s.Add(ch)
+
+	s.Remove(id)
+	s.Dispatch([]string{"0xwork"})
+
+	select {
+	case v := <-ch:
+		t.Fatalf("expected no dispatch after Remove, got %v", v)
+	default:
+	}
+}
@@ -0,0 +1,74 @@
+package gateway
+
+import "sync"
+
+// workHistorySize is how many recent jobs OrderedWorkMap keeps around,
+// which bounds how stale a submitted share is allowed to be before it's
+// rejected as unknown.
+const workHistorySize = 8
+
+// OrderedWorkMap stores recent work history in a fixed-size ring buffer
+// keyed by header hash, so appending a job never needs to shift a slice
+// under a single global lock the way the old version did. Writes take
+// the RWMutex exclusively; `Get` lookups go through a sync.Map fast path
+// first and only fall back to the mutex-guarded ring on a miss.
+type OrderedWorkMap struct {
+	mux    sync.RWMutex
+	ring   [workHistorySize][]string
+	hashes [workHistorySize]string
+	head   int
+	count  int
+
+	recent sync.Map // headerHash string -> []string
+}
+
+// Init initializes the OrderedWorkMap
+func (o *OrderedWorkMap) Init() {
+	o.head = 0
+	o.count = 0
+}
+
+// Append adds a new job to the ring buffer, evicting the oldest one once
+// the buffer is full.
+func (o *OrderedWorkMap) Append(headerHash string, work []string) {
+	o.mux.Lock()
+	if evicted := o.hashes[o.head]; evicted != "" {
+		o.recent.Delete(evicted)
+	}
+
+	o.ring[o.head] = work
+	o.hashes[o.head] = headerHash
+	o.recent.Store(headerHash, work)
+
+	o.head = (o.head + 1) % workHistorySize
+	if o.count < workHistorySize {
+		o.count++
+	}
+	o.mux.Unlock()
+}
+
+// Get looks up a job by header hash, returning false if it's not in the
+// history (either never seen, or already evicted).
+func (o *OrderedWorkMap) Get(headerHash string) ([]string, bool) {
+	if v, ok := o.recent.Load(headerHash); ok {
+		return v.([]string), true
+	}
+
+	o.mux.RLock()
+	defer o.mux.RUnlock()
+	for i := 1; i <= o.count; i++ {
+		idx := (o.head - i + workHistorySize) % workHistorySize
+		if o.hashes[idx] == headerHash {
+			return o.ring[idx], true
+		}
+	}
+	return nil, false
+}
+
+// Len returns the number of jobs currently held.
+func (o *OrderedWorkMap) Len() int {
+	o.mux.RLock()
+	out := o.count
+	o.mux.RUnlock()
+	return out
+}
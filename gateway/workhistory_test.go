@@ -0,0 +1,43 @@
+package gateway
+
+import "testing"
+
+func TestOrderedWorkMapGetAndEvict(t *testing.T) {
+	var m OrderedWorkMap
+	m.Init()
+
+	for i := 0; i < workHistorySize; i++ {
+		hash := "0xhash" + string(rune('a'+i))
+		m.Append(hash, []string{hash})
+	}
+	if got := m.Len(); got != workHistorySize {
+		t.Fatalf("Len() = %d, want %d", got, workHistorySize)
+	}
+
+	firstHash := "0xhasha"
+	if _, ok := m.Get(firstHash); !ok {
+		t.Fatalf("expected %q to still be present", firstHash)
+	}
+
+	// One more Append should evict the oldest entry (firstHash) without
+	// growing past workHistorySize.
+	m.Append("0xhashnew", []string{"0xhashnew"})
+	if got := m.Len(); got != workHistorySize {
+		t.Fatalf("Len() after eviction = %d, want %d", got, workHistorySize)
+	}
+	if _, ok := m.Get(firstHash); ok {
+		t.Fatalf("expected %q to have been evicted", firstHash)
+	}
+	if work, ok := m.Get("0xhashnew"); !ok || work[0] != "0xhashnew" {
+		t.Fatalf("expected newly appended entry to be retrievable")
+	}
+}
+
+func TestOrderedWorkMapUnknownHash(t *testing.T) {
+	var m OrderedWorkMap
+	m.Init()
+
+	if _, ok := m.Get("0xneverappended"); ok {
+		t.Fatalf("expected lookup of an unseen hash to miss")
+	}
+}
@@ -8,59 +8,23 @@ import (
 	"math/big"
 	"net/http"
 	"strconv"
-	"sync"
+	"sync/atomic"
 
 	"github.com/flexpool/solo/log"
 	"github.com/flexpool/solo/nodeapi"
 	"github.com/flexpool/solo/utils"
-
-	"github.com/sirupsen/logrus"
 )
 
-// OrderedWorkMap is used to store work history, and have an ability to prune unneeded work
-type OrderedWorkMap struct {
-	Map   map[string][]string
-	Order []string
-	Mux   sync.Mutex
-}
-
-// Init initializes the OrderedWorkMap
-func (o *OrderedWorkMap) Init() {
-	o.Map = make(map[string][]string)
-}
-
-// Append appends new work to the OrderedWorkMap
-func (o *OrderedWorkMap) Append(headerHash string, work []string) {
-	o.Mux.Lock()
-	o.Map[headerHash] = work
-	o.Order = append(o.Order, headerHash)
-	o.Mux.Unlock()
-}
-
-// Shift removes the first OrderedWorkMap key
-func (o *OrderedWorkMap) Shift() {
-	o.Mux.Lock()
-	headerHash := o.Order[0]
-	delete(o.Map, headerHash)
-	o.Order = o.Order[1:]
-	o.Mux.Unlock()
-}
-
-// Len returns the OrderedWorkMap length
-func (o *OrderedWorkMap) Len() int {
-	o.Mux.Lock()
-	out := len(o.Order)
-	o.Mux.Unlock()
-	return out
-}
-
 // WorkManager is a struct for the work manager daemon
 type WorkManager struct {
-	httpServer        *http.Server
-	shuttingDown      bool
-	subscriptions     []chan []string
-	subscriptionsMux  sync.Mutex
-	lastWork          []string
+	httpServer   *http.Server
+	shuttingDown bool
+	subscribers  *subscriberSet
+	// lastWork holds the most recent job (as a []string) behind an
+	// atomic.Value: handleNewWork stores a new one on every job, while
+	// every Stratum connection goroutine loads it from
+	// mining.authorize/verifyShare, so a plain field would race.
+	lastWork          atomic.Value
 	workHistory       OrderedWorkMap
 	shareDiff         uint64
 	shareTargetHex    string
@@ -70,9 +34,14 @@ type WorkManager struct {
 	Node              *nodeapi.Node
 }
 
+// currentWork loads the most recently dispatched job.
+func (w *WorkManager) currentWork() []string {
+	return w.lastWork.Load().([]string)
+}
+
 // GetLastWork returns last work
 func (w *WorkManager) GetLastWork(applyShareDiff bool) []string {
-	work := w.lastWork
+	work := append([]string(nil), w.currentWork()...)
 	// Apply Share Diff
 	if applyShareDiff {
 		work[2] = w.shareTargetHex
@@ -89,16 +58,16 @@ func NewWorkManager(bind string, shareDiff uint64, node *nodeapi.Node) *WorkMana
 		shareDiffBigInt:   big.NewInt(0).SetUint64(shareDiff),
 		shareTargetBigInt: shareTargetBigInt,
 		shareTargetHex:    "0x" + hex.EncodeToString(utils.PadByteArrayStart(shareTargetBigInt.Bytes(), 32)),
-		lastWork:          []string{"0x0", "0x0", "0x0", "0x0"},
 		BestShareTarget:   big.NewInt(0).Exp(big.NewInt(2), big.NewInt(256), big.NewInt(0)),
 		Node:              node,
 	}
+	workManager.lastWork.Store([]string{"0x0", "0x0", "0x0", "0x0"})
 
 	mux := http.NewServeMux()
 
 	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != "POST" {
-			log.Logger.WithFields(logrus.Fields{
+			log.WithFields(log.Fields{
 				"prefix":   "workreceiver",
 				"expected": "POST",
 				"got":      r.Method,
@@ -109,7 +78,7 @@ func NewWorkManager(bind string, shareDiff uint64, node *nodeapi.Node) *WorkMana
 		var parsedJSONData []string
 		err = json.Unmarshal(data, &parsedJSONData)
 		if err != nil {
-			log.Logger.WithFields(logrus.Fields{
+			log.WithFields(log.Fields{
 				"prefix": "workreceiver",
 				"error":  err,
 			}).Error("Unable to parse OpenEthereum work notification")
@@ -117,7 +86,7 @@ func NewWorkManager(bind string, shareDiff uint64, node *nodeapi.Node) *WorkMana
 		}
 
 		if len(parsedJSONData) != 4 {
-			log.Logger.WithFields(logrus.Fields{
+			log.WithFields(log.Fields{
 				"prefix":   "workreceiver",
 				"expected": 4,
 				"got":      len(parsedJSONData),
@@ -125,42 +94,7 @@ func NewWorkManager(bind string, shareDiff uint64, node *nodeapi.Node) *WorkMana
 			return
 		}
 
-		var channelIndexesToClean []int
-
-		workManager.lastWork = parsedJSONData
-
-		workWithShareDifficulty := parsedJSONData
-		workWithShareDifficulty[2] = workManager.shareTargetHex
-
-		// Sending work notification to all subscribers
-		workManager.subscriptionsMux.Lock()
-		for i, ch := range workManager.subscriptions {
-			if !isChanClosed(ch) {
-				ch <- parsedJSONData
-			} else {
-				channelIndexesToClean = append(channelIndexesToClean, i)
-			}
-		}
-
-		length := len(workManager.subscriptions)
-
-		for _, chIndex := range channelIndexesToClean {
-			workManager.subscriptions[chIndex] = workManager.subscriptions[length-1]
-			workManager.subscriptions = workManager.subscriptions[:length-1]
-		}
-		workManager.subscriptionsMux.Unlock()
-
-		workManager.workHistory.Append(parsedJSONData[0], parsedJSONData)
-
-		if workManager.workHistory.Len() > 8 {
-			// Removing unneeded (9th in history) work
-			workManager.workHistory.Shift()
-		}
-
-		log.Logger.WithFields(logrus.Fields{
-			"prefix":      "workreceiver",
-			"header-hash": parsedJSONData[0][2:10],
-		}).Info("New job for #" + strconv.FormatUint(utils.MustSoftHexToUint64(parsedJSONData[3]), 10))
+		workManager.handleNewWork(parsedJSONData)
 	})
 
 	workManager.httpServer = &http.Server{
@@ -168,13 +102,59 @@ func NewWorkManager(bind string, shareDiff uint64, node *nodeapi.Node) *WorkMana
 		Handler: mux,
 	}
 
+	workManager.subscribers = newSubscriberSet()
 	workManager.workHistory.Init()
 
 	return &workManager
 }
 
+// NewSubscriptionWorkManager creates a WorkManager that owns its own
+// connection to the node (see nodeapi.NewSubscriptionNode) instead of
+// waiting for OpenEthereum to POST work to an HTTP listener. Run starts
+// the node's newHeads subscription rather than an HTTP server.
+func NewSubscriptionWorkManager(nodeEndpoint string, shareDiff uint64) *WorkManager {
+	shareTargetBigInt := big.NewInt(0).Div(big.NewInt(0).Exp(big.NewInt(2), big.NewInt(256), big.NewInt(0)), big.NewInt(0).SetUint64(shareDiff))
+	workManager := &WorkManager{
+		shareDiff:         shareDiff,
+		shareDiffBigInt:   big.NewInt(0).SetUint64(shareDiff),
+		shareTargetBigInt: shareTargetBigInt,
+		shareTargetHex:    "0x" + hex.EncodeToString(utils.PadByteArrayStart(shareTargetBigInt.Bytes(), 32)),
+		BestShareTarget:   big.NewInt(0).Exp(big.NewInt(2), big.NewInt(256), big.NewInt(0)),
+	}
+	workManager.lastWork.Store([]string{"0x0", "0x0", "0x0", "0x0"})
+
+	workManager.subscribers = newSubscriberSet()
+	workManager.workHistory.Init()
+	workManager.Node = nodeapi.NewSubscriptionNode(nodeEndpoint, workManager.handleNewWork)
+
+	return workManager
+}
+
+// handleNewWork records a freshly received job, fans it out to every
+// subscriber, and appends it to the work history, regardless of whether
+// it arrived via the HTTP getWork push or a node subscription.
+func (w *WorkManager) handleNewWork(parsedJSONData []string) {
+	w.lastWork.Store(parsedJSONData)
+
+	w.subscribers.Dispatch(parsedJSONData)
+
+	// The ring buffer evicts the oldest entry on its own once full, so
+	// there's no separate prune step here.
+	w.workHistory.Append(parsedJSONData[0], parsedJSONData)
+
+	log.WithFields(log.Fields{
+		"prefix":      "workreceiver",
+		"header-hash": log.Hash(parsedJSONData[0]),
+	}).Info("New job for #" + strconv.FormatUint(utils.MustSoftHexToUint64(parsedJSONData[3]), 10))
+}
+
 // Run function runs the WorkReceiver
 func (w *WorkManager) Run() {
+	if w.httpServer == nil {
+		w.Node.Run()
+		return
+	}
+
 	err := w.httpServer.ListenAndServe()
 
 	if !w.shuttingDown {
@@ -184,23 +164,37 @@ func (w *WorkManager) Run() {
 
 // Stop function stops the WorkReceiver
 func (w *WorkManager) Stop() {
+	if w.httpServer == nil {
+		w.Node.Stop()
+		return
+	}
+
 	err := w.httpServer.Shutdown(context.Background())
 	if err != nil {
 		panic(err)
 	}
 }
 
-// SubscribeNotifications subscribes the given channel to the work receiver
-func (w *WorkManager) SubscribeNotifications(ch chan []string) {
-	w.subscriptions = append(w.subscriptions, ch)
+// SubscribeNotifications subscribes the given channel to the work
+// receiver, returning a subscription id for later UnsubscribeNotifications.
+func (w *WorkManager) SubscribeNotifications(ch chan []string) uint64 {
+	return w.subscribers.Add(ch)
 }
 
-func isChanClosed(ch <-chan []string) bool {
-	select {
-	case <-ch:
-		return true
-	default:
+// UnsubscribeNotifications removes a subscription added via
+// SubscribeNotifications.
+func (w *WorkManager) UnsubscribeNotifications(id uint64) {
+	w.subscribers.Remove(id)
+}
+
+// SubmitWork submits a found block back to the node. In subscription
+// mode this goes out over the same persistent RPC connection used to
+// receive work; in HTTP push mode the node has no submission channel of
+// its own to offer, so this is a no-op.
+func (w *WorkManager) SubmitWork(nonce, headerHash, mixDigest string) (bool, error) {
+	if w.httpServer != nil {
+		return false, nil
 	}
 
-	return false
-}
\ No newline at end of file
+	return w.Node.SubmitWork(nonce, headerHash, mixDigest)
+}
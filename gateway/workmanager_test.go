@@ -0,0 +1,58 @@
+package gateway
+
+import (
+	"sync"
+	"testing"
+)
+
+// TestWorkManagerLastWorkConcurrentAccess reproduces the race the
+// maintainer flagged: handleNewWork stores a new job on every tick while
+// every Stratum connection goroutine reads the last one via
+// currentWork/GetLastWork concurrently. Run with -race.
+func TestWorkManagerLastWorkConcurrentAccess(t *testing.T) {
+	wm := NewWorkManager("127.0.0.1:0", 1, nil)
+
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+					_ = wm.currentWork()
+					_ = wm.GetLastWork(true)
+				}
+			}
+		}()
+	}
+
+	for i := 0; i < 200; i++ {
+		wm.handleNewWork([]string{"0xhash", "0xseed", "0xtarget", "0x1"})
+	}
+	close(stop)
+	wg.Wait()
+}
+
+// TestWorkManagerGetLastWorkDoesNotMutateStoredJob guards against a copy
+// of the old aliasing bug: GetLastWork(true) overwrites index 2 with the
+// share target for the caller it returns to, and must not leave that
+// change visible in the job every other caller (and currentWork) sees.
+func TestWorkManagerGetLastWorkDoesNotMutateStoredJob(t *testing.T) {
+	wm := NewWorkManager("127.0.0.1:0", 1, nil)
+	wm.handleNewWork([]string{"0xhash", "0xseed", "0xnetworktarget", "0x1"})
+
+	work := wm.GetLastWork(true)
+	if work[2] != wm.shareTargetHex {
+		t.Fatalf("GetLastWork(true)[2] = %q, want share target %q", work[2], wm.shareTargetHex)
+	}
+
+	stored := wm.currentWork()
+	if stored[2] != "0xnetworktarget" {
+		t.Fatalf("GetLastWork mutated the stored job: [2] = %q, want %q", stored[2], "0xnetworktarget")
+	}
+}
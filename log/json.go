@@ -0,0 +1,91 @@
+package log
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+)
+
+// jsonLogger formats log lines as newline-delimited JSON objects, suitable
+// for ingestion by a log collector. Field values are marshaled in full,
+// even ones that implement TerminalStringer.
+type jsonLogger struct {
+	w      io.Writer
+	mux    *sync.Mutex
+	level  Level
+	fields Fields
+}
+
+type jsonLine struct {
+	Time   string `json:"time"`
+	Level  string `json:"level"`
+	Msg    string `json:"msg"`
+	Fields Fields `json:"fields,omitempty"`
+}
+
+// NewJSONLogger creates a Logger that writes newline-delimited JSON lines
+// to w, dropping anything below minLevel.
+func NewJSONLogger(w io.Writer, minLevel Level) Logger {
+	return &jsonLogger{w: w, mux: &sync.Mutex{}, level: minLevel}
+}
+
+func (j *jsonLogger) WithFields(fields Fields) Logger {
+	merged := make(Fields, len(j.fields)+len(fields))
+	for k, v := range j.fields {
+		merged[k] = v
+	}
+	for k, v := range fields {
+		merged[k] = v
+	}
+	return &jsonLogger{w: j.w, mux: j.mux, level: j.level, fields: merged}
+}
+
+func (j *jsonLogger) log(level Level, msg string) {
+	if level < j.level {
+		return
+	}
+
+	data, err := json.Marshal(jsonLine{
+		Time:   time.Now().UTC().Format(time.RFC3339Nano),
+		Level:  level.String(),
+		Msg:    msg,
+		Fields: errorSafeFields(j.fields),
+	})
+	if err != nil {
+		return
+	}
+
+	j.mux.Lock()
+	j.w.Write(append(data, '\n'))
+	j.mux.Unlock()
+}
+
+// errorSafeFields returns fields with any error value replaced by its
+// Error() string. encoding/json marshals a raw error as "{}" (it has no
+// exported fields of its own), which would otherwise silently drop every
+// error message passed via log.Fields{"error": err}.
+func errorSafeFields(fields Fields) Fields {
+	var out Fields
+	for k, v := range fields {
+		if e, ok := v.(error); ok {
+			if out == nil {
+				out = make(Fields, len(fields))
+				for k2, v2 := range fields {
+					out[k2] = v2
+				}
+			}
+			out[k] = e.Error()
+		}
+	}
+	if out != nil {
+		return out
+	}
+	return fields
+}
+
+func (j *jsonLogger) Debug(msg string) { j.log(LevelDebug, msg) }
+func (j *jsonLogger) Info(msg string)  { j.log(LevelInfo, msg) }
+func (j *jsonLogger) Warn(msg string)  { j.log(LevelWarn, msg) }
+func (j *jsonLogger) Error(msg string) { j.log(LevelError, msg) }
+func (j *jsonLogger) Crit(msg string)  { j.log(LevelCrit, msg) }
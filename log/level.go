@@ -0,0 +1,32 @@
+package log
+
+// Level is a logging severity level.
+type Level int
+
+// Severity levels, lowest to highest.
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+	LevelCrit
+)
+
+// String returns the short, uppercase name of the level, as used by both
+// the terminal and JSON formatters.
+func (l Level) String() string {
+	switch l {
+	case LevelDebug:
+		return "DEBUG"
+	case LevelInfo:
+		return "INFO"
+	case LevelWarn:
+		return "WARN"
+	case LevelError:
+		return "ERROR"
+	case LevelCrit:
+		return "CRIT"
+	default:
+		return "UNKNOWN"
+	}
+}
@@ -0,0 +1,67 @@
+// Package log provides the pluggable, structured logger used across solo.
+//
+// Call sites build up context with Fields and WithFields, the same way the
+// old direct-logrus calls did, but the concrete formatting (human-readable
+// terminal output vs. machine-readable JSON) is chosen once at startup via
+// SetLogger, so neither gateway nor db need to know or care which one is
+// active.
+package log
+
+import "os"
+
+// Fields is a structured set of key/value pairs attached to a log line.
+type Fields map[string]interface{}
+
+// TerminalStringer lets a value supply a shortened form of itself for the
+// human-readable terminal format (e.g. a long header hash or IP address),
+// while still marshaling in full for JSON output.
+type TerminalStringer interface {
+	TerminalString() string
+}
+
+// Logger is the logging interface used throughout solo. Implementations
+// are free to route output to a terminal, JSON, or anything else; callers
+// should never assume a particular backend is active.
+type Logger interface {
+	Debug(msg string)
+	Info(msg string)
+	Warn(msg string)
+	Error(msg string)
+	Crit(msg string)
+	WithFields(fields Fields) Logger
+}
+
+// DefaultLogger is the package-level logger used by the rest of solo. It
+// defaults to a terminal-formatted logger writing to stderr, and is meant
+// to be replaced once at startup via SetLogger, before any subsystem is
+// started.
+var DefaultLogger Logger = NewTerminalLogger(os.Stderr, LevelInfo)
+
+// SetLogger replaces the package-level logger. It should be called once,
+// early in startup, before WorkManager/db/nodeapi begin logging.
+func SetLogger(l Logger) {
+	DefaultLogger = l
+}
+
+// WithFields attaches fields to the package-level logger, returning a
+// child Logger scoped to them. This mirrors the former
+// `log.Logger.WithFields(...)` call style so existing call sites only
+// need to drop the `.Logger`.
+func WithFields(fields Fields) Logger {
+	return DefaultLogger.WithFields(fields)
+}
+
+// Debug logs through the package-level logger.
+func Debug(msg string) { DefaultLogger.Debug(msg) }
+
+// Info logs through the package-level logger.
+func Info(msg string) { DefaultLogger.Info(msg) }
+
+// Warn logs through the package-level logger.
+func Warn(msg string) { DefaultLogger.Warn(msg) }
+
+// Error logs through the package-level logger.
+func Error(msg string) { DefaultLogger.Error(msg) }
+
+// Crit logs through the package-level logger.
+func Crit(msg string) { DefaultLogger.Crit(msg) }
@@ -0,0 +1,71 @@
+package log
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"time"
+)
+
+// terminalLogger formats log lines for a human reading a terminal: a
+// timestamp, the level, the message, and then "key=value" context pairs,
+// using TerminalStringer's shortened form wherever a field value
+// implements it.
+type terminalLogger struct {
+	w      io.Writer
+	mux    *sync.Mutex
+	level  Level
+	fields Fields
+}
+
+// NewTerminalLogger creates a Logger that writes human-readable lines to
+// w, dropping anything below minLevel.
+func NewTerminalLogger(w io.Writer, minLevel Level) Logger {
+	return &terminalLogger{w: w, mux: &sync.Mutex{}, level: minLevel}
+}
+
+func (t *terminalLogger) WithFields(fields Fields) Logger {
+	merged := make(Fields, len(t.fields)+len(fields))
+	for k, v := range t.fields {
+		merged[k] = v
+	}
+	for k, v := range fields {
+		merged[k] = v
+	}
+	return &terminalLogger{w: t.w, mux: t.mux, level: t.level, fields: merged}
+}
+
+func (t *terminalLogger) log(level Level, msg string) {
+	if level < t.level {
+		return
+	}
+
+	keys := make([]string, 0, len(t.fields))
+	for k := range t.fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	line := fmt.Sprintf("[%s] %-5s %s", time.Now().Format("2006-01-02 15:04:05"), level, msg)
+	for _, k := range keys {
+		line += " " + k + "=" + terminalValue(t.fields[k])
+	}
+
+	t.mux.Lock()
+	fmt.Fprintln(t.w, line)
+	t.mux.Unlock()
+}
+
+func terminalValue(v interface{}) string {
+	if ts, ok := v.(TerminalStringer); ok {
+		return ts.TerminalString()
+	}
+	return fmt.Sprintf("%v", v)
+}
+
+func (t *terminalLogger) Debug(msg string) { t.log(LevelDebug, msg) }
+func (t *terminalLogger) Info(msg string)  { t.log(LevelInfo, msg) }
+func (t *terminalLogger) Warn(msg string)  { t.log(LevelWarn, msg) }
+func (t *terminalLogger) Error(msg string) { t.log(LevelError, msg) }
+func (t *terminalLogger) Crit(msg string)  { t.log(LevelCrit, msg) }
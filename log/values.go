@@ -0,0 +1,54 @@
+package log
+
+// IP, WorkerName and Hash wrap raw strings so call sites can mark a field
+// as something that should be shortened in the terminal format (they
+// implement TerminalStringer) while still round-tripping in full under
+// JSON, where a plain string MarshalJSON is all that's needed.
+
+// IP is a field value for an IP address.
+type IP string
+
+// TerminalString returns the IP unchanged, unless it's a long-form
+// (IPv6-ish) address, in which case it's shortened for terminal display.
+func (ip IP) TerminalString() string {
+	return truncateMiddle(string(ip), 15)
+}
+
+func (ip IP) String() string { return string(ip) }
+
+// WorkerName is a field value for a miner-supplied worker name.
+type WorkerName string
+
+// TerminalString shortens long worker names for terminal display.
+func (w WorkerName) TerminalString() string {
+	return truncateEnd(string(w), 20)
+}
+
+func (w WorkerName) String() string { return string(w) }
+
+// Hash is a field value for a block header hash or similar 0x-prefixed
+// hex identifier.
+type Hash string
+
+// TerminalString shortens a hash down to its leading bytes, the same way
+// WorkManager used to hand-slice "header-hash" fields.
+func (h Hash) TerminalString() string {
+	return truncateEnd(string(h), 10)
+}
+
+func (h Hash) String() string { return string(h) }
+
+func truncateEnd(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n] + "…"
+}
+
+func truncateMiddle(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	half := (n - 1) / 2
+	return s[:half] + "…" + s[len(s)-half:]
+}
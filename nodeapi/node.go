@@ -0,0 +1,209 @@
+// Package nodeapi talks to the upstream go-ethereum-family node solo is
+// mining against.
+package nodeapi
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/flexpool/solo/log"
+)
+
+// errNotConnected is returned by SubmitWork/SubmitHashrate/getWork while
+// Run hasn't yet established (or is re-establishing, after a dropped
+// connection) a client.
+var errNotConnected = errors.New("nodeapi: not connected to node")
+
+// WorkFunc is called with a fresh eth_getWork result every time a
+// subscribed Node sees a new head.
+type WorkFunc func(work []string)
+
+// Node is a connection to a single node. A Node created by
+// NewSubscriptionNode owns the connection itself: it subscribes to
+// `eth_subscribe("newHeads")`, polls `eth_getWork` on every head, and
+// reconnects with backoff if the connection drops. Node has no way to
+// be handed work from outside; in the OpenEthereum `getWork` HTTP push
+// mode, WorkManager receives work itself and a Node never connects.
+type Node struct {
+	endpoint string
+	onWork   WorkFunc
+
+	// clientMux guards client, which Run reassigns on every (re)connect
+	// while SubmitWork/SubmitHashrate/Stop read it from other goroutines.
+	clientMux sync.Mutex
+	client    *rpc.Client
+
+	stopCh chan struct{}
+	doneCh chan struct{}
+}
+
+// NewSubscriptionNode creates a Node that dials endpoint (a ws:// or
+// ipc path) itself and calls onWork with the result of eth_getWork every
+// time a new head is announced. Call Run to start it.
+func NewSubscriptionNode(endpoint string, onWork WorkFunc) *Node {
+	return &Node{
+		endpoint: endpoint,
+		onWork:   onWork,
+		stopCh:   make(chan struct{}),
+		doneCh:   make(chan struct{}),
+	}
+}
+
+func (n *Node) setClient(c *rpc.Client) {
+	n.clientMux.Lock()
+	n.client = c
+	n.clientMux.Unlock()
+}
+
+func (n *Node) getClient() *rpc.Client {
+	n.clientMux.Lock()
+	defer n.clientMux.Unlock()
+	return n.client
+}
+
+// Run dials the node and services newHeads notifications until Stop is
+// called, reconnecting with exponential backoff (capped at 30s) whenever
+// the connection drops.
+func (n *Node) Run() {
+	defer close(n.doneCh)
+
+	backoff := time.Second
+
+	for {
+		select {
+		case <-n.stopCh:
+			return
+		default:
+		}
+
+		client, err := rpc.Dial(n.endpoint)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"prefix":   "nodeapi",
+				"endpoint": n.endpoint,
+				"error":    err,
+			}).Error("Failed to connect to node, retrying")
+			if !n.sleep(backoff) {
+				return
+			}
+			backoff = nextBackoff(backoff)
+			continue
+		}
+
+		n.setClient(client)
+		backoff = time.Second
+
+		log.WithFields(log.Fields{
+			"prefix":   "nodeapi",
+			"endpoint": n.endpoint,
+		}).Info("Connected to node")
+
+		if err := n.subscribeLoop(); err != nil {
+			log.WithFields(log.Fields{
+				"prefix": "nodeapi",
+				"error":  err,
+			}).Error("Node subscription dropped, reconnecting")
+		}
+
+		client.Close()
+		n.setClient(nil)
+		if !n.sleep(backoff) {
+			return
+		}
+		backoff = nextBackoff(backoff)
+	}
+}
+
+// Stop shuts the node connection down and blocks until Run's goroutine
+// has observed the signal and returned.
+func (n *Node) Stop() {
+	close(n.stopCh)
+	if client := n.getClient(); client != nil {
+		client.Close()
+	}
+	<-n.doneCh
+}
+
+func (n *Node) subscribeLoop() error {
+	client := n.getClient()
+	heads := make(chan map[string]interface{})
+	sub, err := client.Subscribe(context.Background(), "eth", heads, "newHeads")
+	if err != nil {
+		return err
+	}
+	defer sub.Unsubscribe()
+
+	for {
+		select {
+		case <-n.stopCh:
+			return nil
+		case err := <-sub.Err():
+			return err
+		case <-heads:
+			work, err := n.getWork()
+			if err != nil {
+				log.WithFields(log.Fields{
+					"prefix": "nodeapi",
+					"error":  err,
+				}).Warn("eth_getWork failed after new head")
+				continue
+			}
+			n.onWork(work)
+		}
+	}
+}
+
+func (n *Node) getWork() ([]string, error) {
+	client := n.getClient()
+	if client == nil {
+		return nil, errNotConnected
+	}
+	var work []string
+	err := client.CallContext(context.Background(), &work, "eth_getWork")
+	return work, err
+}
+
+// SubmitWork submits a found block over the persistent node connection,
+// the same way the HTTP-push WorkManager would over `eth_submitWork`.
+func (n *Node) SubmitWork(nonce, headerHash, mixDigest string) (bool, error) {
+	client := n.getClient()
+	if client == nil {
+		return false, errNotConnected
+	}
+	var accepted bool
+	err := client.CallContext(context.Background(), &accepted, "eth_submitWork", nonce, headerHash, mixDigest)
+	return accepted, err
+}
+
+// SubmitHashrate reports a miner's hashrate to the node over the same
+// connection, via `eth_submitHashrate`.
+func (n *Node) SubmitHashrate(hashrateHex, id string) (bool, error) {
+	client := n.getClient()
+	if client == nil {
+		return false, errNotConnected
+	}
+	var accepted bool
+	err := client.CallContext(context.Background(), &accepted, "eth_submitHashrate", hashrateHex, id)
+	return accepted, err
+}
+
+// sleep waits for d, or returns false early if Stop is called.
+func (n *Node) sleep(d time.Duration) bool {
+	select {
+	case <-time.After(d):
+		return true
+	case <-n.stopCh:
+		return false
+	}
+}
+
+func nextBackoff(d time.Duration) time.Duration {
+	d *= 2
+	if d > 30*time.Second {
+		return 30 * time.Second
+	}
+	return d
+}
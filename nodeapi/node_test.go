@@ -0,0 +1,75 @@
+package nodeapi
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNextBackoffDoublesAndCapsAt30Seconds(t *testing.T) {
+	cases := []struct {
+		in   time.Duration
+		want time.Duration
+	}{
+		{time.Second, 2 * time.Second},
+		{10 * time.Second, 20 * time.Second},
+		{20 * time.Second, 30 * time.Second},
+		{30 * time.Second, 30 * time.Second},
+	}
+
+	for _, c := range cases {
+		if got := nextBackoff(c.in); got != c.want {
+			t.Errorf("nextBackoff(%s) = %s, want %s", c.in, got, c.want)
+		}
+	}
+}
+
+func TestSubmitWorkWithoutAConnectionReturnsAnError(t *testing.T) {
+	n := NewSubscriptionNode("ws://unused", func(work []string) {})
+
+	if _, err := n.SubmitWork("0x1", "0xaaaa", "0xdeadbeef"); err == nil {
+		t.Fatalf("expected an error submitting work before a connection is established")
+	}
+}
+
+func TestSubmitHashrateWithoutAConnectionReturnsAnError(t *testing.T) {
+	n := NewSubscriptionNode("ws://unused", func(work []string) {})
+
+	if _, err := n.SubmitHashrate("0x1", "0xid"); err == nil {
+		t.Fatalf("expected an error submitting hashrate before a connection is established")
+	}
+}
+
+// TestStopIsSynchronousWithRun exercises Stop's documented guarantee
+// that it doesn't return until Run's goroutine has: an endpoint with an
+// unsupported scheme makes rpc.Dial fail immediately, so Run spends its
+// time in the dial-retry backoff sleep, which is exactly the path
+// Stop's stopCh signal needs to interrupt.
+func TestStopIsSynchronousWithRun(t *testing.T) {
+	n := NewSubscriptionNode("not-a-real-scheme://nowhere", func(work []string) {})
+
+	go n.Run()
+
+	// Give Run a moment to fail its first dial and enter the backoff sleep,
+	// which is exactly the path Stop's stopCh signal needs to interrupt.
+	time.Sleep(20 * time.Millisecond)
+
+	stopped := make(chan struct{})
+	go func() {
+		n.Stop()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+	case <-time.After(2 * time.Second):
+		t.Fatalf("Stop did not return promptly; Run's goroutine appears stuck")
+	}
+
+	// Stop only returns once Run has closed doneCh, so this is just
+	// confirming that guarantee held rather than testing anything new.
+	select {
+	case <-n.doneCh:
+	default:
+		t.Fatalf("Stop returned before Run's doneCh was closed")
+	}
+}